@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// --- 10. ALERTAS DE CAMBIO DE TASA ---
+//
+// Una alerta vigila una divisa y dispara un webhook firmado con
+// HMAC-SHA256 cuando la tasa cruza un umbral ('above'/'below') o cambia
+// más de un porcentaje dado en una ventana de tiempo ('pct_change').
+// Se evalúan todas las alertas activas al final de cada ciclo de
+// updateRatesForCodes.
+
+type Alert struct {
+	ID            int             `json:"id"`
+	CurrencyCode  string          `json:"currency_code"`
+	Condition     string          `json:"condition"`
+	Threshold     decimal.Decimal `json:"threshold"`
+	WindowSeconds int             `json:"window_seconds"`
+	WebhookURL    string          `json:"webhook_url"`
+	Secret        string          `json:"secret,omitempty"`
+	Active        bool            `json:"active"`
+}
+
+// alertPatch espeja Alert con punteros para que un PUT parcial sólo
+// sobrescriba los campos que el cliente realmente envió.
+type alertPatch struct {
+	CurrencyCode  *string          `json:"currency_code"`
+	Condition     *string          `json:"condition"`
+	Threshold     *decimal.Decimal `json:"threshold"`
+	WindowSeconds *int             `json:"window_seconds"`
+	WebhookURL    *string          `json:"webhook_url"`
+	Secret        *string          `json:"secret"`
+	Active        *bool            `json:"active"`
+}
+
+func (p alertPatch) applyTo(a Alert) Alert {
+	if p.CurrencyCode != nil {
+		a.CurrencyCode = strings.ToUpper(*p.CurrencyCode)
+	}
+	if p.Condition != nil {
+		a.Condition = *p.Condition
+	}
+	if p.Threshold != nil {
+		a.Threshold = *p.Threshold
+	}
+	if p.WindowSeconds != nil {
+		a.WindowSeconds = *p.WindowSeconds
+	}
+	if p.WebhookURL != nil {
+		a.WebhookURL = *p.WebhookURL
+	}
+	if p.Secret != nil {
+		a.Secret = *p.Secret
+	}
+	if p.Active != nil {
+		a.Active = *p.Active
+	}
+	return a
+}
+
+func (app *App) createAlertsTable(ctx context.Context) {
+	app.DB.Exec(ctx, `CREATE TABLE IF NOT EXISTS alerts (
+		id SERIAL PRIMARY KEY,
+		currency_code CHAR(3) NOT NULL,
+		condition VARCHAR(16) NOT NULL CHECK (condition IN ('above', 'below', 'pct_change')),
+		threshold DECIMAL(18, 8) NOT NULL,
+		window_seconds INTEGER NOT NULL DEFAULT 0,
+		webhook_url TEXT NOT NULL,
+		secret VARCHAR(64) NOT NULL DEFAULT '',
+		active BOOLEAN NOT NULL DEFAULT true
+	);`)
+}
+
+func (app *App) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.listAlerts(w, r)
+	case http.MethodPost:
+		app.createAlert(w, r)
+	default:
+		http.Error(w, "Método no permitido", 405)
+	}
+}
+
+func (app *App) listAlerts(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query(r.Context(), `SELECT id, currency_code, condition, threshold, window_seconds, webhook_url, active FROM alerts ORDER BY id`)
+	if err != nil {
+		http.Error(w, "Error DB", 500)
+		return
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		if err := rows.Scan(&a.ID, &a.CurrencyCode, &a.Condition, &a.Threshold, &a.WindowSeconds, &a.WebhookURL, &a.Active); err != nil {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// validateAlert aplica las mismas reglas que createAlert exige al crear
+// una alerta; handleAlertByID las reutiliza tras fusionar el parche con
+// el registro existente, para que un PUT parcial no pueda dejar la
+// alerta en un estado inconsistente.
+func validateAlert(a Alert) error {
+	if len(a.CurrencyCode) != 3 || a.WebhookURL == "" {
+		return fmt.Errorf("currency_code y webhook_url son obligatorios")
+	}
+	switch a.Condition {
+	case "above", "below", "pct_change":
+	default:
+		return fmt.Errorf("condition debe ser 'above', 'below' o 'pct_change'")
+	}
+	if err := validateWebhookURL(a.WebhookURL); err != nil {
+		return fmt.Errorf("webhook_url inválida: %w", err)
+	}
+	return nil
+}
+
+// validateWebhookURL rechaza esquemas distintos de http(s), hosts sin
+// resolver y cualquier IP que caiga en rangos privados/loopback/enlace
+// local (incluyendo 169.254.169.254, el endpoint de metadatos en la
+// mayoría de nubes) para que un alertador no pueda usarse como pivote de
+// SSRF hacia servicios internos. WEBHOOK_ALLOWED_HOSTS, si está
+// configurada, restringe además a una lista explícita de hosts.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("esquema no soportado %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("falta el host")
+	}
+
+	if allowed := os.Getenv("WEBHOOK_ALLOWED_HOSTS"); allowed != "" {
+		ok := false
+		for _, host := range strings.Split(allowed, ",") {
+			if strings.EqualFold(strings.TrimSpace(host), u.Hostname()) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("host %q no está en WEBHOOK_ALLOWED_HOSTS", u.Hostname())
+		}
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("no se pudo resolver el host: %w", err)
+	}
+	for _, ip := range ips {
+		if isDisallowedWebhookIP(ip) {
+			return fmt.Errorf("el host resuelve a una dirección no permitida (%s)", ip)
+		}
+	}
+
+	return nil
+}
+
+func isDisallowedWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	return ip.Equal(net.IPv4(169, 254, 169, 254))
+}
+
+func (app *App) createAlert(w http.ResponseWriter, r *http.Request) {
+	var a Alert
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+
+	a.CurrencyCode = strings.ToUpper(a.CurrencyCode)
+	if err := validateAlert(a); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	err := app.DB.QueryRow(r.Context(), `INSERT INTO alerts (currency_code, condition, threshold, window_seconds, webhook_url, secret, active)
+		VALUES ($1, $2, $3, $4, $5, $6, true) RETURNING id`,
+		a.CurrencyCode, a.Condition, a.Threshold, a.WindowSeconds, a.WebhookURL, a.Secret).Scan(&a.ID)
+	if err != nil {
+		http.Error(w, "Error creando alerta", 500)
+		return
+	}
+	a.Active = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+func (app *App) handleAlertByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/alerts/"))
+	if err != nil {
+		http.Error(w, "ID inválido", 400)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var existing Alert
+		err := app.DB.QueryRow(r.Context(), `SELECT id, currency_code, condition, threshold, window_seconds, webhook_url, secret, active
+			FROM alerts WHERE id=$1`, id).
+			Scan(&existing.ID, &existing.CurrencyCode, &existing.Condition, &existing.Threshold,
+				&existing.WindowSeconds, &existing.WebhookURL, &existing.Secret, &existing.Active)
+		if err != nil {
+			http.Error(w, "Alerta no encontrada", 404)
+			return
+		}
+
+		var patch alertPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "JSON inválido", 400)
+			return
+		}
+		merged := patch.applyTo(existing)
+
+		if err := validateAlert(merged); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		_, err = app.DB.Exec(r.Context(), `UPDATE alerts SET currency_code=$1, condition=$2, threshold=$3,
+			window_seconds=$4, webhook_url=$5, secret=$6, active=$7 WHERE id=$8`,
+			merged.CurrencyCode, merged.Condition, merged.Threshold, merged.WindowSeconds, merged.WebhookURL, merged.Secret, merged.Active, id)
+		if err != nil {
+			http.Error(w, "Error actualizando alerta", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(merged)
+
+	case http.MethodDelete:
+		if _, err := app.DB.Exec(r.Context(), `DELETE FROM alerts WHERE id=$1`, id); err != nil {
+			http.Error(w, "Error eliminando alerta", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "eliminado"})
+
+	default:
+		http.Error(w, "Método no permitido", 405)
+	}
+}
+
+// evaluateAlerts compara las tasas recién ingresadas contra su valor
+// previo (antes del upsert) o, para 'pct_change' con window_seconds > 0,
+// contra el valor de hace window_seconds, y dispara el webhook de cada
+// alerta que cumpla su condición.
+func (app *App) evaluateAlerts(ctx context.Context, previous, current map[string]decimal.Decimal) {
+	rows, err := app.DB.Query(ctx, `SELECT id, currency_code, condition, threshold, window_seconds, webhook_url, secret
+		FROM alerts WHERE active = true`)
+	if err != nil {
+		log.Println("Error leyendo alertas activas:", err)
+		return
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		if err := rows.Scan(&a.ID, &a.CurrencyCode, &a.Condition, &a.Threshold, &a.WindowSeconds, &a.WebhookURL, &a.Secret); err != nil {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+
+	for _, a := range alerts {
+		rate, ok := current[a.CurrencyCode]
+		if !ok {
+			continue
+		}
+
+		triggered, comparedTo := app.alertTriggered(ctx, a, rate, previous[a.CurrencyCode])
+		if !triggered {
+			continue
+		}
+
+		go app.dispatchWebhook(a, rate, comparedTo)
+	}
+}
+
+func (app *App) alertTriggered(ctx context.Context, a Alert, rate, previous decimal.Decimal) (bool, decimal.Decimal) {
+	switch a.Condition {
+	case "above":
+		// Sólo dispara al cruzar: el valor previo no estaba ya por encima.
+		return rate.GreaterThan(a.Threshold) && !previous.GreaterThan(a.Threshold), a.Threshold
+	case "below":
+		// Un previo en cero significa que no había tasa previa.
+		return rate.LessThan(a.Threshold) && (previous.IsZero() || !previous.LessThan(a.Threshold)), a.Threshold
+	case "pct_change":
+		baseline := previous
+		if a.WindowSeconds > 0 {
+			if windowed, _, err := app.FindRateAt(ctx, a.CurrencyCode, time.Now().Add(-time.Duration(a.WindowSeconds)*time.Second)); err == nil {
+				baseline = windowed
+			}
+		}
+		if baseline.IsZero() {
+			return false, baseline
+		}
+		change := rate.Sub(baseline).Div(baseline).Abs()
+		return change.GreaterThanOrEqual(a.Threshold), baseline
+	default:
+		return false, decimal.Decimal{}
+	}
+}
+
+// webhookClient limita cuánto puede colgar un endpoint de cliente no
+// responsivo; sin esto, cada ciclo de ingesta que dispara una alerta
+// hacia un webhook caído acumula una goroutine bloqueada indefinidamente.
+//
+// El Transport usa un DialContext a medida en vez del dialer por
+// defecto: validateWebhookURL sólo resuelve el host en el momento de
+// crear/editar la alerta, pero el DNS puede cambiar después (DNS
+// rebinding) para que el mismo hostname apunte a una IP prohibida
+// (p.ej. 169.254.169.254) en el momento del envío real. safeWebhookDial
+// vuelve a resolver y a filtrar las IPs justo antes de conectar, y fija
+// la conexión a la IP ya comprobada, para que esa ventana entre
+// validación y conexión no sirva de bypass del filtro SSRF.
+var webhookClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: &http.Transport{DialContext: safeWebhookDial},
+}
+
+// safeWebhookDial resuelve addr, descarta cualquier IP que
+// isDisallowedWebhookIP rechace y conecta directamente a la primera IP
+// permitida que quede, para que la conexión quede fijada a una IP ya
+// comprobada en vez de volver a confiar en el resolver del sistema.
+func safeWebhookDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo resolver el host: %w", err)
+	}
+
+	var dialer net.Dialer
+	for _, ip := range ips {
+		if isDisallowedWebhookIP(ip.IP) {
+			continue
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+	}
+	return nil, fmt.Errorf("el host %q no resuelve a ninguna IP permitida", host)
+}
+
+// dispatchWebhook envía el payload de la alerta firmado con
+// HMAC-SHA256(secret, body) en la cabecera X-Signature; si la alerta no
+// tiene secret, se envía sin esa cabecera.
+func (app *App) dispatchWebhook(a Alert, rate, comparedTo decimal.Decimal) {
+	body, err := json.Marshal(map[string]interface{}{
+		"alert_id": a.ID, "currency_code": a.CurrencyCode, "condition": a.Condition,
+		"threshold": a.Threshold, "rate": rate, "compared_to": comparedTo,
+		"triggered_at": time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, a.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Println("Error creando request de webhook:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if a.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(a.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		log.Printf("⚠️  Webhook de alerta %d falló: %v", a.ID, err)
+		return
+	}
+	resp.Body.Close()
+}
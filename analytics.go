@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// --- 9. ANALÍTICA SOBRE rate_history ---
+//
+// GET /analytics agrupa rate_history en buckets (open/high/low/close,
+// media y desviación estándar) usando funciones de ventana, y añade
+// volatilidad y máximo drawdown sobre todo el rango. GET /analytics/compare
+// normaliza varias divisas a base=100 en el primer punto del rango para
+// poder graficar rendimiento relativo. Ambos codifican la respuesta en
+// streaming para no acumular rangos largos en memoria.
+
+// parseBucket traduce sufijos cortos (15m, 1h, 1d) al formato de
+// intervalo de Postgres.
+func parseBucket(raw string) (string, error) {
+	if raw == "" {
+		raw = "1d"
+	}
+	if len(raw) < 2 {
+		return "", fmt.Errorf("bucket inválido: %q", raw)
+	}
+
+	unit := raw[len(raw)-1]
+	qty := raw[:len(raw)-1]
+
+	switch unit {
+	case 'm':
+		return qty + " minutes", nil
+	case 'h':
+		return qty + " hours", nil
+	case 'd':
+		return qty + " days", nil
+	default:
+		return "", fmt.Errorf("unidad de bucket no soportada: %q", raw)
+	}
+}
+
+func parseAnalyticsRange(r *http.Request) (code string, from, to time.Time, bucket string, err error) {
+	code = strings.ToUpper(r.URL.Query().Get("code"))
+	if len(code) != 3 {
+		err = fmt.Errorf("se requiere código de moneda (parámetro 'code')")
+		return
+	}
+
+	from, err = time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		err = fmt.Errorf("parámetro 'from' inválido, se espera RFC3339")
+		return
+	}
+
+	to, err = time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		err = fmt.Errorf("parámetro 'to' inválido, se espera RFC3339")
+		return
+	}
+
+	bucket, err = parseBucket(r.URL.Query().Get("bucket"))
+	return
+}
+
+type ohlcBucket struct {
+	Bucket time.Time       `json:"bucket"`
+	Open   decimal.Decimal `json:"open"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Close  decimal.Decimal `json:"close"`
+	Mean   decimal.Decimal `json:"mean"`
+	Stddev decimal.Decimal `json:"stddev"`
+}
+
+// writeJSONStream escribe un array JSON leyendo de next() hasta que
+// devuelva ok=false, sin mantener toda la colección en memoria.
+func writeJSONStream(w io.Writer, next func() (interface{}, bool)) {
+	fmt.Fprint(w, "[")
+	first := true
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if !first {
+			fmt.Fprint(w, ",")
+		}
+		first = false
+		enc, _ := json.Marshal(item)
+		w.Write(enc)
+	}
+	fmt.Fprint(w, "]")
+}
+
+func (app *App) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	code, from, to, bucket, err := parseAnalyticsRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	rows, err := app.DB.Query(r.Context(), `
+		WITH bucketed AS (
+			SELECT date_bin($4::interval, recorded_at, $2::timestamptz) AS bucket_at,
+				rate, recorded_at,
+				first_value(rate) OVER w AS open,
+				last_value(rate) OVER w AS close,
+				max(rate) OVER (PARTITION BY date_bin($4::interval, recorded_at, $2::timestamptz)) AS high,
+				min(rate) OVER (PARTITION BY date_bin($4::interval, recorded_at, $2::timestamptz)) AS low,
+				avg(rate) OVER (PARTITION BY date_bin($4::interval, recorded_at, $2::timestamptz)) AS mean,
+				stddev(rate) OVER (PARTITION BY date_bin($4::interval, recorded_at, $2::timestamptz)) AS stddev
+			FROM rate_history
+			WHERE currency_code = $1 AND recorded_at BETWEEN $2 AND $3
+			WINDOW w AS (PARTITION BY date_bin($4::interval, recorded_at, $2::timestamptz)
+				ORDER BY recorded_at ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)
+		)
+		SELECT DISTINCT ON (bucket_at) bucket_at, open, high, low, close, mean, stddev
+		FROM bucketed ORDER BY bucket_at ASC`, code, from, to, bucket)
+	if err != nil {
+		http.Error(w, "Error interno", 500)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"code":%q,"from":%q,"to":%q,"bucket":%q,"buckets":`,
+		code, from.Format(time.RFC3339), to.Format(time.RFC3339), r.URL.Query().Get("bucket"))
+
+	// volatility y max_drawdown se acumulan fila a fila según se va
+	// transmitiendo el array de buckets (ver volatilityAccumulator), en
+	// vez de sobre una copia completa de los cierres, para que el rango
+	// no quede retenido dos veces en memoria y el endpoint cumpla lo que
+	// su doc comment promete sobre codificar en streaming.
+	acc := newVolatilityAccumulator()
+	writeJSONStream(w, func() (interface{}, bool) {
+		if !rows.Next() {
+			return nil, false
+		}
+		var b ohlcBucket
+		var stddev *decimal.Decimal
+		if err := rows.Scan(&b.Bucket, &b.Open, &b.High, &b.Low, &b.Close, &b.Mean, &stddev); err != nil {
+			return nil, false
+		}
+		if stddev != nil {
+			b.Stddev = *stddev
+		}
+		acc.add(b.Close)
+		return b, true
+	})
+
+	volatility, maxDrawdown := acc.result()
+	fmt.Fprintf(w, `,"volatility":%s,"max_drawdown":%s}`, jsonFloat(volatility), jsonFloat(maxDrawdown))
+}
+
+func jsonFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "null"
+	}
+	return fmt.Sprintf("%.6f", f)
+}
+
+// volatilityAccumulator calcula stddev/mean (vía el algoritmo online de
+// Welford) y el mayor drawdown observado sin retener la serie completa
+// en memoria: cada valor se consume con add() y se descarta, por lo que
+// sirve tanto para un slice ya en memoria como para ir acumulando fila a
+// fila mientras se transmite una respuesta en streaming.
+type volatilityAccumulator struct {
+	n     int
+	mean  float64
+	m2    float64
+	peak  float64
+	maxDD float64
+}
+
+func newVolatilityAccumulator() *volatilityAccumulator {
+	return &volatilityAccumulator{}
+}
+
+func (a *volatilityAccumulator) add(close decimal.Decimal) {
+	v, _ := close.Float64()
+
+	a.n++
+	delta := v - a.mean
+	a.mean += delta / float64(a.n)
+	a.m2 += delta * (v - a.mean)
+
+	if a.n == 1 || v > a.peak {
+		a.peak = v
+	}
+	if a.peak > 0 {
+		if drawdown := (a.peak - v) / a.peak; drawdown > a.maxDD {
+			a.maxDD = drawdown
+		}
+	}
+}
+
+func (a *volatilityAccumulator) result() (volatility, maxDrawdown float64) {
+	if a.n == 0 {
+		return 0, 0
+	}
+	stddev := math.Sqrt(a.m2 / float64(a.n))
+	if a.mean != 0 {
+		volatility = stddev / a.mean
+	}
+	return volatility, a.maxDD
+}
+
+// volatilityAndDrawdown calcula stddev/mean sobre los cierres y el mayor
+// drawdown (caída desde un máximo local) observado en la serie.
+func volatilityAndDrawdown(closes []decimal.Decimal) (volatility, maxDrawdown float64) {
+	acc := newVolatilityAccumulator()
+	for _, c := range closes {
+		acc.add(c)
+	}
+	return acc.result()
+}
+
+func (app *App) handleAnalyticsCompare(w http.ResponseWriter, r *http.Request) {
+	codesParam := r.URL.Query().Get("codes")
+	if codesParam == "" {
+		http.Error(w, "Se requiere parámetro 'codes'", 400)
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Parámetro 'from' inválido, se espera RFC3339", 400)
+		return
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Parámetro 'to' inválido, se espera RFC3339", 400)
+		return
+	}
+
+	codes := strings.Split(strings.ToUpper(codesParam), ",")
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "{")
+	for i, code := range codes {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		enc, _ := json.Marshal(code)
+		w.Write(enc)
+		fmt.Fprint(w, ":")
+		app.streamNormalizedSeries(w, r, code, from, to)
+	}
+	fmt.Fprint(w, "}")
+}
+
+// streamNormalizedSeries escribe, como array JSON, la serie de rate_history
+// de code en [from, to] normalizada a base=100 sobre el primer punto.
+func (app *App) streamNormalizedSeries(w http.ResponseWriter, r *http.Request, code string, from, to time.Time) {
+	rows, err := app.DB.Query(r.Context(), `SELECT rate, recorded_at FROM rate_history
+		WHERE currency_code = $1 AND recorded_at BETWEEN $2 AND $3 ORDER BY recorded_at ASC`, code, from, to)
+	if err != nil {
+		fmt.Fprint(w, "[]")
+		return
+	}
+	defer rows.Close()
+
+	var base decimal.Decimal
+	hasBase := false
+
+	writeJSONStream(w, func() (interface{}, bool) {
+		if !rows.Next() {
+			return nil, false
+		}
+		var rate decimal.Decimal
+		var recordedAt time.Time
+		if err := rows.Scan(&rate, &recordedAt); err != nil {
+			return nil, false
+		}
+		if !hasBase {
+			base = rate
+			hasBase = true
+		}
+		normalized := decimal.NewFromInt(100)
+		if !base.IsZero() {
+			normalized = rate.Div(base).Mul(decimal.NewFromInt(100))
+		}
+		return map[string]interface{}{
+			"recorded_at": recordedAt.Format(time.RFC3339),
+			"rate":        rate.Round(6),
+			"normalized":  normalized.Round(4),
+		}, true
+	})
+}
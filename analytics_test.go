@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func decimals(values ...float64) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(values))
+	for i, v := range values {
+		out[i] = decimal.NewFromFloat(v)
+	}
+	return out
+}
+
+func TestVolatilityAndDrawdownEmpty(t *testing.T) {
+	volatility, maxDrawdown := volatilityAndDrawdown(nil)
+	if volatility != 0 || maxDrawdown != 0 {
+		t.Fatalf("expected zeros for empty input, got volatility=%v maxDrawdown=%v", volatility, maxDrawdown)
+	}
+}
+
+func TestVolatilityAndDrawdownFlatSeries(t *testing.T) {
+	volatility, maxDrawdown := volatilityAndDrawdown(decimals(1.1, 1.1, 1.1))
+	if volatility != 0 {
+		t.Fatalf("expected zero volatility for a flat series, got %v", volatility)
+	}
+	if maxDrawdown != 0 {
+		t.Fatalf("expected zero drawdown for a flat series, got %v", maxDrawdown)
+	}
+}
+
+func TestVolatilityAndDrawdownKnownSeries(t *testing.T) {
+	// Pico en 120, caída hasta 80 => drawdown de 40/120 (~33,3%).
+	volatility, maxDrawdown := volatilityAndDrawdown(decimals(100, 120, 80, 90))
+
+	wantDrawdown := (120.0 - 80.0) / 120.0
+	if math.Abs(maxDrawdown-wantDrawdown) > 1e-9 {
+		t.Fatalf("maxDrawdown = %v, want %v", maxDrawdown, wantDrawdown)
+	}
+	if volatility <= 0 {
+		t.Fatalf("expected positive volatility for a non-flat series, got %v", volatility)
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"", "1 days", false},
+		{"1d", "1 days", false},
+		{"6h", "6 hours", false},
+		{"30m", "30 minutes", false},
+		{"1w", "", true},
+		{"x", "", true},
+	}
+
+	for _, c := range cases {
+		got, err := parseBucket(c.raw)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("parseBucket(%q) error = %v, wantErr %v", c.raw, err, c.wantErr)
+		}
+		if err == nil && got != c.want {
+			t.Fatalf("parseBucket(%q) = %q, want %q", c.raw, got, c.want)
+		}
+	}
+}
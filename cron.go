@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 7. PROGRAMADOR DE ACTUALIZACIONES ---
+//
+// Reemplaza el ticker fijo de 24h por un conjunto de Jobs con su propia
+// expresión cron, subconjunto de divisas y estado de última ejecución.
+// El formato soportado es el cron estándar de 5 campos:
+// minuto hora día-del-mes mes día-de-semana (todos admiten "*" y "*/N").
+
+type cronField struct {
+	wildcard bool
+	step     int
+	values   map[int]bool
+}
+
+func parseCronField(field string, min, max int) (cronField, error) {
+	if field == "*" {
+		return cronField{wildcard: true, step: 1}, nil
+	}
+
+	if strings.HasPrefix(field, "*/") {
+		step, err := strconv.Atoi(field[2:])
+		if err != nil || step <= 0 {
+			return cronField{}, fmt.Errorf("paso inválido en %q", field)
+		}
+		// Como en cron estándar, "*/N" cuenta desde el mínimo del campo
+		// (p.ej. día-del-mes */2 = 1,3,5,...).
+		values := make(map[int]bool)
+		for n := min; n <= max; n += step {
+			values[n] = true
+		}
+		return cronField{step: step, values: values}, nil
+	}
+
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < min || n > max {
+			return cronField{}, fmt.Errorf("valor inválido %q (rango %d-%d)", part, min, max)
+		}
+		values[n] = true
+	}
+	return cronField{values: values}, nil
+}
+
+func (f cronField) matches(n int) bool {
+	if f.wildcard {
+		return true
+	}
+	if f.step > 0 && f.values == nil {
+		return n%f.step == 0
+	}
+	return f.values[n]
+}
+
+// CronSchedule es un parser interno mínimo para expresiones cron de 5
+// campos, suficiente para cadencias de refresco (no soporta listas de
+// rangos tipo "1-5").
+type CronSchedule struct {
+	raw        string
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+}
+
+func parseCronSchedule(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("se esperaban 5 campos cron, se recibieron %d en %q", len(fields), expr)
+	}
+
+	minute, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+	hour, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+	dom, err := parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+	month, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+	dow, err := parseCronField(fields[4], 0, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CronSchedule{raw: expr, minute: minute, hour: hour, dayOfMonth: dom, month: month, dayOfWeek: dow}, nil
+}
+
+// Next devuelve la próxima ejecución estrictamente posterior a from,
+// buscando minuto a minuto hasta dos años hacia adelante.
+func (s *CronSchedule) Next(from time.Time) time.Time {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	limit := from.AddDate(2, 0, 0)
+
+	for t.Before(limit) {
+		if s.month.matches(int(t.Month())) && s.dayOfMonth.matches(t.Day()) &&
+			s.dayOfWeek.matches(int(t.Weekday())) && s.hour.matches(t.Hour()) &&
+			s.minute.matches(t.Minute()) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// Job representa una tarea de refresco periódica, opcionalmente acotada a
+// un subconjunto de divisas (p.ej. refresco rápido de majors vs. diario
+// para exóticas).
+type Job struct {
+	Name     string
+	Schedule *CronSchedule
+	Codes    []string
+	Jitter   time.Duration
+
+	mu           sync.Mutex
+	nextRun      time.Time
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastStatus   string
+	lastError    string
+}
+
+func (app *App) runJob(ctx context.Context, job *Job) {
+	start := time.Now()
+	err := app.updateRatesForCodes(ctx, job.Codes)
+
+	job.mu.Lock()
+	job.lastRun = start
+	job.lastDuration = time.Since(start)
+	if err != nil {
+		job.lastStatus = "error"
+		job.lastError = err.Error()
+	} else {
+		job.lastStatus = "ok"
+		job.lastError = ""
+	}
+	job.mu.Unlock()
+}
+
+// startScheduler ejecuta cada job inmediatamente y luego respeta su propia
+// expresión cron, con jitter aleatorio para no golpear todos los
+// proveedores en el mismo instante.
+func (app *App) startScheduler(ctx context.Context) {
+	for _, job := range app.Jobs {
+		job := job
+		go func() {
+			app.runJob(ctx, job)
+
+			for {
+				job.mu.Lock()
+				next := job.Schedule.Next(time.Now())
+				job.nextRun = next
+				job.mu.Unlock()
+
+				if next.IsZero() {
+					log.Printf("⚠️  El job %q no tiene próxima ejecución calculable", job.Name)
+					return
+				}
+
+				jitter := time.Duration(0)
+				if job.Jitter > 0 {
+					jitter = time.Duration(rand.Int63n(int64(job.Jitter)))
+				}
+
+				select {
+				case <-time.After(time.Until(next) + jitter):
+					app.runJob(ctx, job)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// loadJobs arma los jobs a partir de UPDATE_CRON_JOBS, con el formato
+// "nombre:expresión_cron:COD1,COD2;nombre2:expresión_cron:" (divisas vacías
+// = todas). Si no está configurada, cae a un único job "default" leyendo
+// UPDATE_CRON (o cada 24h a medianoche si tampoco está presente).
+func loadJobs() []*Job {
+	if raw := os.Getenv("UPDATE_CRON_JOBS"); raw != "" {
+		var jobs []*Job
+		for _, spec := range strings.Split(raw, ";") {
+			parts := strings.SplitN(spec, ":", 3)
+			if len(parts) < 2 {
+				log.Printf("⚠️  Job mal formado ignorado: %q", spec)
+				continue
+			}
+
+			schedule, err := parseCronSchedule(parts[1])
+			if err != nil {
+				log.Printf("⚠️  Cron inválido para job %q: %v", parts[0], err)
+				continue
+			}
+
+			var codes []string
+			if len(parts) == 3 && parts[2] != "" {
+				codes = strings.Split(parts[2], ",")
+			}
+
+			jobs = append(jobs, &Job{Name: parts[0], Schedule: schedule, Codes: codes, Jitter: 30 * time.Second})
+		}
+		if len(jobs) > 0 {
+			return jobs
+		}
+	}
+
+	expr := os.Getenv("UPDATE_CRON")
+	if expr == "" {
+		expr = "0 0 * * *"
+	}
+
+	schedule, err := parseCronSchedule(expr)
+	if err != nil {
+		log.Fatalf("UPDATE_CRON inválido: %v", err)
+	}
+
+	return []*Job{{Name: "default", Schedule: schedule, Jitter: 30 * time.Second}}
+}
+
+func (app *App) handleAdminRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	name := r.URL.Query().Get("job")
+	var target *Job
+	for _, job := range app.Jobs {
+		if name == "" || job.Name == name {
+			target = job
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, "Job no encontrado", 404)
+		return
+	}
+
+	go app.runJob(context.Background(), target)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "disparado", "job": target.Name})
+}
+
+func (app *App) handleAdminJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := make([]map[string]interface{}, 0, len(app.Jobs))
+	for _, job := range app.Jobs {
+		job.mu.Lock()
+		entry := map[string]interface{}{
+			"name":             job.Name,
+			"cron":             job.Schedule.raw,
+			"codes":            job.Codes,
+			"next_run":         job.nextRun,
+			"last_run":         job.lastRun,
+			"last_status":      job.lastStatus,
+			"last_duration_ms": job.lastDuration.Milliseconds(),
+			"last_error":       job.lastError,
+		}
+		job.mu.Unlock()
+		jobs = append(jobs, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
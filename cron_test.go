@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	cases := []struct {
+		name    string
+		field   string
+		min     int
+		max     int
+		wantErr bool
+	}{
+		{"wildcard", "*", 0, 59, false},
+		{"step", "*/15", 0, 59, false},
+		{"list", "0,30", 0, 59, false},
+		{"invalid step", "*/0", 0, 59, true},
+		{"out of range", "99", 0, 59, true},
+		{"garbage", "abc", 0, 59, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parseCronField(c.field, c.min, c.max)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("parseCronField(%q) error = %v, wantErr %v", c.field, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2026, 7, 25, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every six hours lands on next boundary", "0 */6 * * *", time.Date(2026, 7, 25, 12, 0, 0, 0, time.UTC)},
+		{"daily at midnight rolls to next day", "0 0 * * *", time.Date(2026, 7, 26, 0, 0, 0, 0, time.UTC)},
+		{"every minute advances by one minute", "* * * * *", time.Date(2026, 7, 25, 10, 1, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(c.expr)
+			if err != nil {
+				t.Fatalf("parseCronSchedule(%q) error: %v", c.expr, err)
+			}
+			got := schedule.Next(from)
+			if !got.Equal(c.want) {
+				t.Fatalf("Next(%v) = %v, want %v", from, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	if _, err := parseCronSchedule("* * *"); err == nil {
+		t.Fatal("expected error for expression with too few fields")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// --- 8. METADATOS DE DIVISAS ---
+//
+// La tabla currencies guarda el catálogo de divisas soportadas (fiat,
+// cripto o metales) junto a cuántos decimales usar al redondear
+// conversiones. Los códigos desconocidos que llegan por el ingest se dan
+// de alta como inactivos, pendientes de aprobación manual vía el
+// endpoint de administración.
+
+type Currency struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Symbol        string `json:"symbol"`
+	DecimalDigits int    `json:"decimal_digits"`
+	IsActive      bool   `json:"is_active"`
+	Kind          string `json:"kind"`
+}
+
+var seedCurrencies = []Currency{
+	{Code: "USD", Name: "US Dollar", Symbol: "$", DecimalDigits: 2, IsActive: true, Kind: "fiat"},
+	{Code: "EUR", Name: "Euro", Symbol: "€", DecimalDigits: 2, IsActive: true, Kind: "fiat"},
+	{Code: "GBP", Name: "British Pound", Symbol: "£", DecimalDigits: 2, IsActive: true, Kind: "fiat"},
+	{Code: "JPY", Name: "Japanese Yen", Symbol: "¥", DecimalDigits: 0, IsActive: true, Kind: "fiat"},
+	{Code: "BHD", Name: "Bahraini Dinar", Symbol: ".د.ب", DecimalDigits: 3, IsActive: true, Kind: "fiat"},
+	{Code: "BTC", Name: "Bitcoin", Symbol: "₿", DecimalDigits: 8, IsActive: true, Kind: "crypto"},
+}
+
+func (app *App) seedCurrencyTable(ctx context.Context) {
+	for _, c := range seedCurrencies {
+		app.DB.Exec(ctx, `INSERT INTO currencies (code, name, symbol, decimal_digits, is_active, kind)
+			VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (code) DO NOTHING;`,
+			c.Code, c.Name, c.Symbol, c.DecimalDigits, c.IsActive, c.Kind)
+	}
+}
+
+// upsertUnknownCurrency da de alta, como inactiva, cualquier código que
+// llegue del ingest y no exista todavía en el catálogo.
+func (app *App) upsertUnknownCurrency(ctx context.Context, code string) {
+	app.DB.Exec(ctx, `INSERT INTO currencies (code, name, symbol, decimal_digits, is_active, kind)
+		VALUES ($1, $1, '', 2, false, 'fiat') ON CONFLICT (code) DO NOTHING;`, code)
+}
+
+func (app *App) getCurrency(ctx context.Context, code string) (Currency, error) {
+	var c Currency
+	err := app.DB.QueryRow(ctx, `SELECT code, name, symbol, decimal_digits, is_active, kind
+		FROM currencies WHERE code = $1`, code).
+		Scan(&c.Code, &c.Name, &c.Symbol, &c.DecimalDigits, &c.IsActive, &c.Kind)
+	return c, err
+}
+
+func (app *App) handleCurrencies(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query(r.Context(), `SELECT code, name, symbol, decimal_digits, is_active, kind
+		FROM currencies ORDER BY code`)
+	if err != nil {
+		http.Error(w, "Error DB", 500)
+		return
+	}
+	defer rows.Close()
+
+	var currencies []Currency
+	for rows.Next() {
+		var c Currency
+		if err := rows.Scan(&c.Code, &c.Name, &c.Symbol, &c.DecimalDigits, &c.IsActive, &c.Kind); err != nil {
+			continue
+		}
+		currencies = append(currencies, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currencies)
+}
+
+// currencyPatch espeja Currency con punteros para que un PUT parcial
+// (p.ej. {"is_active": true} para aprobar una divisa pendiente) sólo
+// sobrescriba los campos que el cliente realmente envió, en vez de
+// pisar el resto con sus zero values. Mismo patrón que alertPatch en
+// alerts.go.
+type currencyPatch struct {
+	Name          *string `json:"name"`
+	Symbol        *string `json:"symbol"`
+	DecimalDigits *int    `json:"decimal_digits"`
+	IsActive      *bool   `json:"is_active"`
+	Kind          *string `json:"kind"`
+}
+
+func (p currencyPatch) applyTo(c Currency) Currency {
+	if p.Name != nil {
+		c.Name = *p.Name
+	}
+	if p.Symbol != nil {
+		c.Symbol = *p.Symbol
+	}
+	if p.DecimalDigits != nil {
+		c.DecimalDigits = *p.DecimalDigits
+	}
+	if p.IsActive != nil {
+		c.IsActive = *p.IsActive
+	}
+	if p.Kind != nil {
+		c.Kind = *p.Kind
+	}
+	return c
+}
+
+func validateCurrency(c Currency) error {
+	if c.Name == "" {
+		return fmt.Errorf("name es obligatorio")
+	}
+	if c.DecimalDigits < 0 {
+		return fmt.Errorf("decimal_digits no puede ser negativo")
+	}
+	switch c.Kind {
+	case "fiat", "crypto", "metal":
+	default:
+		return fmt.Errorf("kind debe ser 'fiat', 'crypto' o 'metal'")
+	}
+	return nil
+}
+
+func (app *App) handleAdminCurrency(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/admin/currencies/"))
+	if len(code) != 3 {
+		http.Error(w, "Código inválido", 400)
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	existing, err := app.getCurrency(r.Context(), code)
+	if err != nil {
+		existing = Currency{Code: code, Name: code, DecimalDigits: 2, Kind: "fiat"}
+	}
+
+	var patch currencyPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+	merged := patch.applyTo(existing)
+	merged.Code = code
+
+	if err := validateCurrency(merged); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	_, err = app.DB.Exec(r.Context(), `INSERT INTO currencies (code, name, symbol, decimal_digits, is_active, kind)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name, symbol = EXCLUDED.symbol,
+			decimal_digits = EXCLUDED.decimal_digits, is_active = EXCLUDED.is_active, kind = EXCLUDED.kind;`,
+		merged.Code, merged.Name, merged.Symbol, merged.DecimalDigits, merged.IsActive, merged.Kind)
+	if err != nil {
+		http.Error(w, "Error actualizando divisa", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
+}
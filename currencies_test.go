@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func strPtr(s string) *string { return &s }
+func intPtr(i int) *int       { return &i }
+func boolPtr(b bool) *bool    { return &b }
+
+func TestCurrencyPatchApplyToPartialUpdate(t *testing.T) {
+	existing := Currency{Code: "XYZ", Name: "XYZ", Symbol: "", DecimalDigits: 2, IsActive: false, Kind: "fiat"}
+
+	patch := currencyPatch{IsActive: boolPtr(true)}
+	merged := patch.applyTo(existing)
+
+	if merged.Name != "XYZ" || merged.Kind != "fiat" || merged.DecimalDigits != 2 {
+		t.Fatalf("partial patch must not zero untouched fields, got %+v", merged)
+	}
+	if !merged.IsActive {
+		t.Fatalf("expected is_active to be applied from the patch, got %+v", merged)
+	}
+}
+
+func TestCurrencyPatchApplyToFullOverride(t *testing.T) {
+	existing := Currency{Code: "XYZ", Name: "old", Symbol: "x", DecimalDigits: 2, IsActive: false, Kind: "fiat"}
+
+	patch := currencyPatch{
+		Name:          strPtr("New Name"),
+		Symbol:        strPtr("N"),
+		DecimalDigits: intPtr(8),
+		IsActive:      boolPtr(true),
+		Kind:          strPtr("crypto"),
+	}
+	merged := patch.applyTo(existing)
+
+	want := Currency{Code: "XYZ", Name: "New Name", Symbol: "N", DecimalDigits: 8, IsActive: true, Kind: "crypto"}
+	if merged != want {
+		t.Fatalf("applyTo() = %+v, want %+v", merged, want)
+	}
+}
+
+func TestValidateCurrency(t *testing.T) {
+	cases := []struct {
+		name    string
+		c       Currency
+		wantErr bool
+	}{
+		{"valid fiat", Currency{Name: "Euro", DecimalDigits: 2, Kind: "fiat"}, false},
+		{"valid crypto", Currency{Name: "Bitcoin", DecimalDigits: 8, Kind: "crypto"}, false},
+		{"empty name", Currency{Name: "", DecimalDigits: 2, Kind: "fiat"}, true},
+		{"negative decimal digits", Currency{Name: "Euro", DecimalDigits: -1, Kind: "fiat"}, true},
+		{"blank kind from a PUT missing the field", Currency{Name: "Euro", DecimalDigits: 2, Kind: ""}, true},
+		{"unsupported kind", Currency{Name: "Euro", DecimalDigits: 2, Kind: "stock"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateCurrency(c.c)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("validateCurrency(%+v) error = %v, wantErr %v", c.c, err, c.wantErr)
+			}
+		})
+	}
+}
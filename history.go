@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// --- 6. HISTÓRICO ENRIQUECIDO ---
+//
+// FindRateAt busca en rate_history la tasa más cercana al instante t para
+// currency_code = code. Primero intenta la más reciente en o antes de t;
+// si no hay ninguna dentro de historyLookupWindow(), recurre a la más
+// cercana después de t (también dentro de esa ventana).
+
+const defaultHistoryLookupWindow = 72 * time.Hour
+
+// historyLookupWindow permite ajustar por entorno cuánto se extiende la
+// búsqueda hacia atrás y la del "siguiente más cercano" vía
+// HISTORY_LOOKUP_WINDOW_HOURS; por defecto, defaultHistoryLookupWindow.
+func historyLookupWindow() time.Duration {
+	raw := os.Getenv("HISTORY_LOOKUP_WINDOW_HOURS")
+	if raw == "" {
+		return defaultHistoryLookupWindow
+	}
+	hours, err := strconv.Atoi(raw)
+	if err != nil || hours <= 0 {
+		return defaultHistoryLookupWindow
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (app *App) FindRateAt(ctx context.Context, code string, t time.Time) (decimal.Decimal, time.Time, error) {
+	var rate decimal.Decimal
+	var recordedAt time.Time
+	window := historyLookupWindow()
+
+	err := app.DB.QueryRow(ctx, `
+		SELECT rate, recorded_at FROM rate_history
+		WHERE currency_code = $1 AND recorded_at <= $2 AND recorded_at >= $3
+		ORDER BY recorded_at DESC LIMIT 1`, code, t, t.Add(-window)).Scan(&rate, &recordedAt)
+	if err == nil {
+		return rate, recordedAt, nil
+	}
+
+	err = app.DB.QueryRow(ctx, `
+		SELECT rate, recorded_at FROM rate_history
+		WHERE currency_code = $1 AND recorded_at > $2 AND recorded_at <= $3
+		ORDER BY recorded_at ASC LIMIT 1`, code, t, t.Add(window)).Scan(&rate, &recordedAt)
+	if err != nil {
+		return decimal.Decimal{}, time.Time{}, err
+	}
+
+	return rate, recordedAt, nil
+}
+
+func (app *App) handleHistoryAt(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(r.URL.Query().Get("code"))
+	if len(code) != 3 {
+		http.Error(w, "Se requiere código de moneda (parámetro 'code')", 400)
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		http.Error(w, "Parámetro 'at' inválido, se espera RFC3339", 400)
+		return
+	}
+
+	rate, recordedAt, err := app.FindRateAt(r.Context(), code, at)
+	if err != nil {
+		http.Error(w, "No se encontró tasa cercana para esa fecha", 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": code, "requested_at": at.Format(time.RFC3339),
+		"rate": rate.Round(6), "recorded_at": recordedAt.Format(time.RFC3339),
+	})
+}
+
+type enrichRequest struct {
+	Code      string          `json:"code"`
+	Timestamp time.Time       `json:"timestamp"`
+	Amount    decimal.Decimal `json:"amount"`
+}
+
+func (app *App) handleHistoryEnrich(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", 405)
+		return
+	}
+
+	var items []enrichRequest
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		code := strings.ToUpper(item.Code)
+		if len(code) != 3 {
+			results = append(results, map[string]interface{}{"code": item.Code, "error": "código inválido"})
+			continue
+		}
+
+		rate, recordedAt, err := app.FindRateAt(r.Context(), code, item.Timestamp)
+		if err != nil {
+			results = append(results, map[string]interface{}{"code": code, "timestamp": item.Timestamp.Format(time.RFC3339), "error": "sin tasa histórica disponible"})
+			continue
+		}
+		if rate.IsZero() {
+			results = append(results, map[string]interface{}{"code": code, "timestamp": item.Timestamp.Format(time.RFC3339), "error": "tasa histórica inválida (cero)"})
+			continue
+		}
+
+		usdValue := item.Amount.Div(rate)
+		results = append(results, map[string]interface{}{
+			"code": code, "timestamp": item.Timestamp.Format(time.RFC3339),
+			"amount": item.Amount, "amount_usd": usdValue.Round(4),
+			"rate_used": rate.Round(6), "rate_recorded_at": recordedAt.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
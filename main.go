@@ -16,14 +16,11 @@ import (
 
 // --- 1. ESTRUCTURAS ---
 
-type APIResponse struct {
-	Success   bool               `json:"success"`
-	Timestamp int64              `json:"timestamp"`
-	Quotes    map[string]float64 `json:"quotes"`
-}
-
 type App struct {
-	DB *pgxpool.Pool
+	DB        *pgxpool.Pool
+	Providers []Provider
+	Jobs      []*Job
+	RateHub   *rateHub
 }
 
 // --- 2. MAIN & CONFIGURACIÓN ---
@@ -43,18 +40,32 @@ func main() {
 	}
 	defer pool.Close()
 
-	app := App{DB: pool}
+	app := App{DB: pool, Providers: loadProviders(), Jobs: loadJobs(), RateHub: newRateHub()}
+	if len(app.Providers) == 0 {
+		log.Println("⚠️  Ningún proveedor configurado (revisa DATA_URL / PROVIDER_*_URL)")
+	}
 
 	// Inicialización
 	app.initDatabase(ctx)
-	go app.startDailyWorker(ctx)
+	app.startScheduler(ctx)
 
 	// Definición de Rutas (Endpoints)
 	http.HandleFunc("/convert", app.handleConvert)
 	http.HandleFunc("/history", app.handleHistory)
+	http.HandleFunc("/history/at", app.handleHistoryAt)
+	http.HandleFunc("/history/enrich", app.handleHistoryEnrich)
 	http.HandleFunc("/latest", app.handleLatest)
 	http.HandleFunc("/rates/", app.handleSingleRate)
 	http.HandleFunc("/check", app.handleCheck)
+	http.HandleFunc("/admin/refresh", app.handleAdminRefresh)
+	http.HandleFunc("/admin/jobs", app.handleAdminJobs)
+	http.HandleFunc("/currencies", app.handleCurrencies)
+	http.HandleFunc("/admin/currencies/", app.handleAdminCurrency)
+	http.HandleFunc("/analytics", app.handleAnalytics)
+	http.HandleFunc("/analytics/compare", app.handleAnalyticsCompare)
+	http.HandleFunc("/alerts", app.handleAlerts)
+	http.HandleFunc("/alerts/", app.handleAlertByID)
+	http.HandleFunc("/stream/rates", app.handleStreamRates)
 
 	fmt.Println("🚀 API de Divisas Robustas iniciada en :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -67,15 +78,27 @@ func (app *App) initDatabase(ctx context.Context) {
 		`CREATE TABLE IF NOT EXISTS exchange_rates (
 			currency_code CHAR(3) PRIMARY KEY,
 			rate_to_base DECIMAL(18, 8) NOT NULL,
+			source VARCHAR(32),
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
 		`CREATE TABLE IF NOT EXISTS rate_history (
 			id SERIAL PRIMARY KEY,
 			currency_code CHAR(3) NOT NULL,
 			rate DECIMAL(18, 8) NOT NULL,
+			source VARCHAR(32),
 			recorded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_history_lookup ON rate_history(currency_code, recorded_at);`,
+		`ALTER TABLE exchange_rates ADD COLUMN IF NOT EXISTS source VARCHAR(32);`,
+		`ALTER TABLE rate_history ADD COLUMN IF NOT EXISTS source VARCHAR(32);`,
+		`CREATE TABLE IF NOT EXISTS currencies (
+			code CHAR(3) PRIMARY KEY,
+			name VARCHAR(64) NOT NULL,
+			symbol VARCHAR(8) NOT NULL DEFAULT '',
+			decimal_digits SMALLINT NOT NULL DEFAULT 2,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			kind VARCHAR(8) NOT NULL DEFAULT 'fiat' CHECK (kind IN ('fiat', 'crypto', 'metal'))
+		);`,
 	}
 
 	for _, q := range queries {
@@ -83,60 +106,87 @@ func (app *App) initDatabase(ctx context.Context) {
 			log.Fatalf("Error creando tablas: %v", err)
 		}
 	}
+	app.seedCurrencyTable(ctx)
+	app.createAlertsTable(ctx)
 	log.Println("✅ Base de datos verificada/creada.")
 }
 
-func (app *App) startDailyWorker(ctx context.Context) {
-	app.updateRates(ctx)
-	ticker := time.NewTicker(24 * time.Hour)
-	for {
-		select {
-		case <-ticker.C:
-			app.updateRates(ctx)
-		case <-ctx.Done():
-			return
-		}
+// updateRatesForCodes sincroniza las tasas de los proveedores configurados.
+// Si codes no está vacío, sólo se conservan y persisten esos códigos
+// (usado por los jobs que refrescan un subconjunto de divisas).
+func (app *App) updateRatesForCodes(ctx context.Context, codes []string) error {
+	log.Println("🔄 Sincronizando datos con proveedor externo...")
+
+	if len(app.Providers) == 0 {
+		return fmt.Errorf("no hay proveedores configurados")
 	}
-}
 
-func (app *App) updateRates(ctx context.Context) {
-	log.Println("🔄 Sincronizando datos con proveedor externo...")
-	url := os.Getenv("DATA_URL")
+	var rates map[string]decimal.Decimal
+	var source string
 
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Println("Error de red:", err)
-		return
+	if os.Getenv("RECONCILE_PROVIDERS") == "true" {
+		threshold := reconcileThreshold()
+		median, divergent, err := app.reconcileQuotes(ctx, threshold)
+		if err != nil {
+			return fmt.Errorf("error de reconciliación: %w", err)
+		}
+		if len(divergent) > 0 {
+			log.Printf("⚠️  Divergencia > %.2f%% detectada en: %s", threshold*100, strings.Join(divergent, ", "))
+		}
+		rates, source = median, "reconciled"
+	} else {
+		fetched, _, providerName, err := app.fetchFailover(ctx)
+		if err != nil {
+			return fmt.Errorf("error obteniendo tasas de todos los proveedores: %w", err)
+		}
+		rates, source = fetched, providerName
 	}
-	defer resp.Body.Close()
 
-	var data APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		log.Println("Error JSON:", err)
-		return
+	if len(codes) > 0 {
+		allowed := make(map[string]bool, len(codes))
+		for _, c := range codes {
+			allowed[strings.ToUpper(c)] = true
+		}
+		for code := range rates {
+			if !allowed[code] {
+				delete(rates, code)
+			}
+		}
+	}
+
+	previous := make(map[string]decimal.Decimal, len(rates))
+	for code := range rates {
+		var prev decimal.Decimal
+		if err := app.DB.QueryRow(ctx, `SELECT rate_to_base FROM exchange_rates WHERE currency_code = $1`, code).Scan(&prev); err == nil {
+			previous[code] = prev
+		}
 	}
 
 	tx, err := app.DB.Begin(ctx)
 	if err != nil {
-		return
+		return err
 	}
 	defer tx.Rollback(ctx)
 
-	for pair, rate := range data.Quotes {
-		code := strings.TrimPrefix(pair, "USD")
-		if code == "" || len(code) != 3 {
-			continue
-		}
+	for code, rate := range rates {
+		tx.Exec(ctx, `INSERT INTO exchange_rates (currency_code, rate_to_base, source, updated_at)
+			VALUES ($1, $2, $3, NOW()) ON CONFLICT (currency_code)
+			DO UPDATE SET rate_to_base = EXCLUDED.rate_to_base, source = EXCLUDED.source, updated_at = NOW();`, code, rate, source)
 
-		tx.Exec(ctx, `INSERT INTO exchange_rates (currency_code, rate_to_base, updated_at)
-			VALUES ($1, $2, NOW()) ON CONFLICT (currency_code) 
-			DO UPDATE SET rate_to_base = EXCLUDED.rate_to_base, updated_at = NOW();`, code, rate)
+		tx.Exec(ctx, `INSERT INTO rate_history (currency_code, rate, source) VALUES ($1, $2, $3);`, code, rate, source)
 
-		tx.Exec(ctx, `INSERT INTO rate_history (currency_code, rate) VALUES ($1, $2);`, code, rate)
+		app.upsertUnknownCurrency(ctx, code)
 	}
 
-	tx.Commit(ctx)
-	log.Println("✅ Sincronización terminada.")
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	app.evaluateAlerts(ctx, previous, rates)
+	app.RateHub.publish(rates)
+
+	log.Printf("✅ Sincronización terminada (fuente: %s, %d divisas).", source, len(rates))
+	return nil
 }
 
 // --- 4. HANDLERS (ENDPOINTS) ---
@@ -159,10 +209,30 @@ func (app *App) handleConvert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fromMeta, err := app.getCurrency(r.Context(), from)
+	if err != nil {
+		http.Error(w, "Divisa no encontrada", 404)
+		return
+	}
+	if !fromMeta.IsActive {
+		http.Error(w, "Divisa inactiva", http.StatusGone)
+		return
+	}
+
+	toMeta, err := app.getCurrency(r.Context(), to)
+	if err != nil {
+		http.Error(w, "Divisa no encontrada", 404)
+		return
+	}
+	if !toMeta.IsActive {
+		http.Error(w, "Divisa inactiva", http.StatusGone)
+		return
+	}
+
 	result := amount.Mul(rateTo).Div(rateFrom)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"from": from, "to": to, "amount": amount, "result": result.Round(4),
+		"from": from, "to": to, "amount": amount, "result": result.Round(int32(toMeta.DecimalDigits)),
 	})
 }
 
@@ -214,7 +284,8 @@ func (app *App) handleHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *App) handleLatest(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query(r.Context(), "SELECT currency_code, rate_to_base, updated_at FROM exchange_rates")
+	rows, err := app.DB.Query(r.Context(), `SELECT e.currency_code, e.rate_to_base, e.updated_at, c.name, c.symbol
+		FROM exchange_rates e LEFT JOIN currencies c ON c.code = e.currency_code`)
 	if err != nil {
 		http.Error(w, "Error DB", 500)
 		return
@@ -226,8 +297,16 @@ func (app *App) handleLatest(w http.ResponseWriter, r *http.Request) {
 		var code string
 		var rate decimal.Decimal
 		var updated time.Time
-		rows.Scan(&code, &rate, &updated)
-		rates[code] = map[string]interface{}{"rate": rate.Round(6), "updated_at": updated}
+		var name, symbol *string
+		rows.Scan(&code, &rate, &updated, &name, &symbol)
+		entry := map[string]interface{}{"rate": rate.Round(6), "updated_at": updated}
+		if name != nil {
+			entry["name"] = *name
+		}
+		if symbol != nil {
+			entry["symbol"] = *symbol
+		}
+		rates[code] = entry
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -250,8 +329,14 @@ func (app *App) handleSingleRate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := map[string]interface{}{"code": code, "rate": rate.Round(6), "base": "USD", "updated_at": updated}
+	if meta, err := app.getCurrency(r.Context(), code); err == nil {
+		response["name"] = meta.Name
+		response["symbol"] = meta.Symbol
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "rate": rate.Round(6), "base": "USD", "updated_at": updated})
+	json.NewEncoder(w).Encode(response)
 }
 
 func (app *App) handleCheck(w http.ResponseWriter, r *http.Request) {
@@ -266,4 +351,4 @@ func (app *App) handleCheck(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "available", "database": "connected", "timestamp": time.Now().Format(time.RFC3339)})
-}
\ No newline at end of file
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// --- 5. PROVEEDORES DE TASAS ---
+//
+// Un Provider sabe cómo obtener tasas de una fuente externa concreta.
+// App mantiene una lista ordenada de proveedores y los intenta en orden
+// de prioridad hasta que uno responda correctamente.
+
+type Provider interface {
+	Name() string
+	FetchRates(ctx context.Context) (map[string]decimal.Decimal, time.Time, error)
+}
+
+// httpGetJSON aplica un timeout por-proveedor y decodifica la respuesta en v.
+func httpGetJSON(ctx context.Context, url string, timeout time.Duration, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proveedor devolvió status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// CurrencyAPIProvider habla el formato de currencyapi.com: data.{CODE}.value
+type CurrencyAPIProvider struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (p *CurrencyAPIProvider) Name() string { return "currencyapi" }
+
+func (p *CurrencyAPIProvider) FetchRates(ctx context.Context) (map[string]decimal.Decimal, time.Time, error) {
+	var payload struct {
+		Data map[string]struct {
+			Value decimal.Decimal `json:"value"`
+		} `json:"data"`
+	}
+
+	if err := httpGetJSON(ctx, p.URL, p.Timeout, &payload); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rates := make(map[string]decimal.Decimal, len(payload.Data))
+	for code, v := range payload.Data {
+		if len(code) != 3 {
+			continue
+		}
+		rates[code] = v.Value
+	}
+	return rates, time.Now(), nil
+}
+
+// ExchangeRateProvider habla el formato de exchangerate-api.com: conversion_rates.{CODE}
+type ExchangeRateProvider struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (p *ExchangeRateProvider) Name() string { return "exchangerate" }
+
+func (p *ExchangeRateProvider) FetchRates(ctx context.Context) (map[string]decimal.Decimal, time.Time, error) {
+	var payload struct {
+		ConversionRates map[string]decimal.Decimal `json:"conversion_rates"`
+		TimeLastUpdate  int64                      `json:"time_last_update_unix"`
+	}
+
+	if err := httpGetJSON(ctx, p.URL, p.Timeout, &payload); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rates := make(map[string]decimal.Decimal, len(payload.ConversionRates))
+	for code, v := range payload.ConversionRates {
+		if len(code) != 3 {
+			continue
+		}
+		rates[strings.ToUpper(code)] = v
+	}
+
+	fetchedAt := time.Now()
+	if payload.TimeLastUpdate > 0 {
+		fetchedAt = time.Unix(payload.TimeLastUpdate, 0)
+	}
+	return rates, fetchedAt, nil
+}
+
+// FrankfurterProvider habla el formato de frankfurter.app: rates.{CODE}, base fijo USD
+type FrankfurterProvider struct {
+	URL     string
+	Timeout time.Duration
+}
+
+func (p *FrankfurterProvider) Name() string { return "frankfurter" }
+
+func (p *FrankfurterProvider) FetchRates(ctx context.Context) (map[string]decimal.Decimal, time.Time, error) {
+	var payload struct {
+		Rates map[string]decimal.Decimal `json:"rates"`
+		Date  string                     `json:"date"`
+	}
+
+	if err := httpGetJSON(ctx, p.URL, p.Timeout, &payload); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	rates := make(map[string]decimal.Decimal, len(payload.Rates))
+	for code, v := range payload.Rates {
+		if len(code) != 3 {
+			continue
+		}
+		rates[strings.ToUpper(code)] = v
+	}
+
+	fetchedAt := time.Now()
+	if t, err := time.Parse("2006-01-02", payload.Date); err == nil {
+		fetchedAt = t
+	}
+	return rates, fetchedAt, nil
+}
+
+const defaultProviderTimeout = 5 * time.Second
+
+// providerTimeout lee "<envPrefix>_TIMEOUT" (en segundos) o cae al
+// timeout por defecto si no está configurado o es inválido.
+func providerTimeout(envPrefix string) time.Duration {
+	raw := os.Getenv(envPrefix + "_TIMEOUT")
+	if raw == "" {
+		return defaultProviderTimeout
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return defaultProviderTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// loadProviders arma la lista de proveedores en orden de prioridad a partir
+// de las variables de entorno. El legado DATA_URL sigue funcionando como
+// proveedor currencyapi si no se configura nada más específico. Cada
+// proveedor admite su propio "<PROVIDER>_URL_TIMEOUT" en segundos.
+func loadProviders() []Provider {
+	var providers []Provider
+
+	if url := os.Getenv("PROVIDER_CURRENCYAPI_URL"); url != "" {
+		providers = append(providers, &CurrencyAPIProvider{URL: url, Timeout: providerTimeout("PROVIDER_CURRENCYAPI_URL")})
+	}
+	if url := os.Getenv("PROVIDER_EXCHANGERATE_URL"); url != "" {
+		providers = append(providers, &ExchangeRateProvider{URL: url, Timeout: providerTimeout("PROVIDER_EXCHANGERATE_URL")})
+	}
+	if url := os.Getenv("PROVIDER_FRANKFURTER_URL"); url != "" {
+		providers = append(providers, &FrankfurterProvider{URL: url, Timeout: providerTimeout("PROVIDER_FRANKFURTER_URL")})
+	}
+
+	if len(providers) == 0 {
+		if url := os.Getenv("DATA_URL"); url != "" {
+			providers = append(providers, &CurrencyAPIProvider{URL: url, Timeout: providerTimeout("DATA_URL")})
+		}
+	}
+
+	return providers
+}
+
+// fetchWithBackoff intenta FetchRates con reintentos y backoff exponencial
+// antes de darse por vencido con un proveedor concreto.
+func fetchWithBackoff(ctx context.Context, p Provider, maxAttempts int) (map[string]decimal.Decimal, time.Time, error) {
+	var lastErr error
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		rates, fetchedAt, err := p.FetchRates(ctx)
+		if err == nil {
+			return rates, fetchedAt, nil
+		}
+		lastErr = err
+		log.Printf("⚠️  Proveedor %s falló (intento %d/%d): %v", p.Name(), attempt, maxAttempts, err)
+
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, time.Time{}, ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	return nil, time.Time{}, lastErr
+}
+
+// fetchFailover recorre app.Providers en orden de prioridad y devuelve el
+// primer resultado exitoso, junto al nombre del proveedor que lo entregó.
+func (app *App) fetchFailover(ctx context.Context) (map[string]decimal.Decimal, time.Time, string, error) {
+	var lastErr error
+
+	for _, p := range app.Providers {
+		rates, fetchedAt, err := fetchWithBackoff(ctx, p, 3)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return rates, fetchedAt, p.Name(), nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no hay proveedores configurados")
+	}
+	return nil, time.Time{}, "", lastErr
+}
+
+// reconcileQuotes obtiene tasas de todos los proveedores en paralelo y
+// devuelve, por cada código, la mediana de los valores reportados. Si la
+// divergencia entre el mínimo y el máximo supera divergenceThreshold
+// (proporción sobre la mediana), el código se añade a la lista devuelta
+// de códigos divergentes pero igualmente se incluye con su mediana.
+const defaultReconcileThreshold = 0.01
+
+// reconcileThreshold lee RECONCILE_THRESHOLD (proporción, p.ej. "0.02"
+// para 2%) o cae al valor por defecto si no está configurada o es inválida.
+func reconcileThreshold() float64 {
+	raw := os.Getenv("RECONCILE_THRESHOLD")
+	if raw == "" {
+		return defaultReconcileThreshold
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold <= 0 {
+		return defaultReconcileThreshold
+	}
+	return threshold
+}
+
+func (app *App) reconcileQuotes(ctx context.Context, divergenceThreshold float64) (map[string]decimal.Decimal, []string, error) {
+	type result struct {
+		provider string
+		rates    map[string]decimal.Decimal
+		err      error
+	}
+
+	results := make(chan result, len(app.Providers))
+	for _, p := range app.Providers {
+		p := p
+		go func() {
+			rates, _, err := fetchWithBackoff(ctx, p, 2)
+			results <- result{provider: p.Name(), rates: rates, err: err}
+		}()
+	}
+
+	byCode := make(map[string][]decimal.Decimal)
+	for range app.Providers {
+		res := <-results
+		if res.err != nil {
+			log.Printf("⚠️  Proveedor %s no disponible para reconciliación: %v", res.provider, res.err)
+			continue
+		}
+		for code, rate := range res.rates {
+			byCode[code] = append(byCode[code], rate)
+		}
+	}
+
+	if len(byCode) == 0 {
+		return nil, nil, fmt.Errorf("ningún proveedor respondió durante la reconciliación")
+	}
+
+	median := make(map[string]decimal.Decimal, len(byCode))
+	var divergent []string
+
+	for code, values := range byCode {
+		sort.Slice(values, func(i, j int) bool { return values[i].LessThan(values[j]) })
+		mid := values[len(values)/2]
+		if len(values)%2 == 0 && len(values) > 1 {
+			mid = values[len(values)/2-1].Add(values[len(values)/2]).Div(decimal.NewFromInt(2))
+		}
+		median[code] = mid
+
+		if len(values) > 1 && !mid.IsZero() {
+			spread := values[len(values)-1].Sub(values[0]).Div(mid)
+			if f, _ := spread.Float64(); math.Abs(f) > divergenceThreshold {
+				divergent = append(divergent, code)
+			}
+		}
+	}
+
+	return median, divergent, nil
+}
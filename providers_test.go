@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type fakeProvider struct {
+	name  string
+	rates map[string]decimal.Decimal
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) FetchRates(ctx context.Context) (map[string]decimal.Decimal, time.Time, error) {
+	return f.rates, time.Now(), nil
+}
+
+func TestReconcileQuotesMedianAgreement(t *testing.T) {
+	app := &App{Providers: []Provider{
+		&fakeProvider{name: "a", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(0.90)}},
+		&fakeProvider{name: "b", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(0.90)}},
+		&fakeProvider{name: "c", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(0.90)}},
+	}}
+
+	median, divergent, err := app.reconcileQuotes(context.Background(), 0.01)
+	if err != nil {
+		t.Fatalf("reconcileQuotes error: %v", err)
+	}
+	if len(divergent) != 0 {
+		t.Fatalf("expected no divergence when providers agree, got %v", divergent)
+	}
+	if !median["EUR"].Equal(decimal.NewFromFloat(0.90)) {
+		t.Fatalf("median[EUR] = %v, want 0.90", median["EUR"])
+	}
+}
+
+func TestReconcileQuotesFlagsDivergence(t *testing.T) {
+	app := &App{Providers: []Provider{
+		&fakeProvider{name: "a", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(0.90)}},
+		&fakeProvider{name: "b", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(0.91)}},
+		&fakeProvider{name: "c", rates: map[string]decimal.Decimal{"EUR": decimal.NewFromFloat(2.00)}},
+	}}
+
+	median, divergent, err := app.reconcileQuotes(context.Background(), 0.01)
+	if err != nil {
+		t.Fatalf("reconcileQuotes error: %v", err)
+	}
+	if len(divergent) != 1 || divergent[0] != "EUR" {
+		t.Fatalf("expected EUR flagged as divergent, got %v", divergent)
+	}
+	if !median["EUR"].Equal(decimal.NewFromFloat(0.91)) {
+		t.Fatalf("median[EUR] = %v, want 0.91 (middle of the three values)", median["EUR"])
+	}
+}
+
+func TestReconcileQuotesNoProvidersRespond(t *testing.T) {
+	app := &App{Providers: nil}
+
+	if _, _, err := app.reconcileQuotes(context.Background(), 0.01); err == nil {
+		t.Fatal("expected an error when no providers are configured")
+	}
+}
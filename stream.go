@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// --- 11. STREAM DE TASAS EN TIEMPO REAL ---
+//
+// rateHub reenvía cada lote de tasas ingresado por updateRatesForCodes a
+// los clientes conectados a GET /stream/rates vía Server-Sent Events,
+// opcionalmente filtrado por el parámetro ?codes=.
+
+type rateHub struct {
+	mu          sync.Mutex
+	subscribers map[chan map[string]decimal.Decimal]struct{}
+}
+
+func newRateHub() *rateHub {
+	return &rateHub{subscribers: make(map[chan map[string]decimal.Decimal]struct{})}
+}
+
+func (h *rateHub) subscribe() chan map[string]decimal.Decimal {
+	ch := make(chan map[string]decimal.Decimal, 8)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *rateHub) unsubscribe(ch chan map[string]decimal.Decimal) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+	close(ch)
+}
+
+func (h *rateHub) publish(rates map[string]decimal.Decimal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- rates:
+		default:
+			// Suscriptor lento: se descarta la actualización en vez de bloquear el ingest.
+		}
+	}
+}
+
+func (app *App) handleStreamRates(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming no soportado", 500)
+		return
+	}
+
+	var filter map[string]bool
+	if codes := r.URL.Query().Get("codes"); codes != "" {
+		filter = make(map[string]bool)
+		for _, c := range strings.Split(strings.ToUpper(codes), ",") {
+			filter[c] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := app.RateHub.subscribe()
+	defer app.RateHub.unsubscribe(ch)
+
+	for {
+		select {
+		case rates, ok := <-ch:
+			if !ok {
+				return
+			}
+			filtered := rates
+			if filter != nil {
+				filtered = make(map[string]decimal.Decimal)
+				for code, rate := range rates {
+					if filter[code] {
+						filtered[code] = rate
+					}
+				}
+				if len(filtered) == 0 {
+					continue
+				}
+			}
+
+			fmt.Fprint(w, "event: rates\ndata: {")
+			first := true
+			for code, rate := range filtered {
+				if !first {
+					fmt.Fprint(w, ",")
+				}
+				first = false
+				fmt.Fprintf(w, "%q:%q", code, rate.Round(6).String())
+			}
+			fmt.Fprint(w, "}\n\n")
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}